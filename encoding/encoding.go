@@ -0,0 +1,198 @@
+/*
+ *
+ * Copyright 2018 gRPC authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package encoding defines the interfaces for the compressor and codec used
+// by a gRPC call, and provides a registry for implementations to register
+// themselves under a name referenced from the wire (grpc-encoding header
+// and content-subtype, respectively).
+package encoding
+
+import (
+	"io"
+	"strings"
+
+	"google.golang.org/grpc/mem"
+)
+
+// Identity specifies the optional encoding for no compression.
+const Identity = "identity"
+
+// Compressor is used for compressing and decompressing when sending or
+// receiving messages. It is the interface registered under a
+// grpc-encoding name by encoding/gzip, encoding/lz4, encoding/snappy, etc.
+type Compressor interface {
+	// Compress writes the data written to the returned io.WriteCloser to w
+	// after compressing it. If an error occurs while creating the writer,
+	// it will be returned.
+	Compress(w io.Writer) (io.WriteCloser, error)
+	// Decompress reads data from r, decompresses it, and provides the
+	// uncompressed data via the returned io.Reader. If an error occurs
+	// while creating the reader, it will be returned.
+	Decompress(r io.Reader) (io.Reader, error)
+	// Name is the name of the compression codec and is used to set the
+	// content-coding header as well as the grpc-encoding header.
+	Name() string
+}
+
+var registeredCompressor = make(map[string]Compressor)
+
+// RegisterCompressor registers the compressor with gRPC by its name. It can
+// be activated when sending an RPC via grpc.UseCompressor(). It will be
+// automatically accessed when receiving a message based on the content
+// coding header. Servers also use it to send a response with the same
+// encoding as the request.
+//
+// NOTE: this function must only be called during initialization time
+// (i.e. in an init() function), and is not thread-safe.
+func RegisterCompressor(c Compressor) {
+	registeredCompressor[c.Name()] = c
+}
+
+// GetCompressor returns Compressor for the given compressor name.
+func GetCompressor(name string) Compressor {
+	return registeredCompressor[name]
+}
+
+// RegisteredCompressorNames returns the names of all Compressors registered
+// via RegisterCompressor. Callers negotiating a compressor out of a peer's
+// offered list (see negotiateCompressor in rpc_util.go) use this to report
+// what this side supports when none of the offered names overlap.
+func RegisteredCompressorNames() []string {
+	names := make([]string, 0, len(registeredCompressor))
+	for name := range registeredCompressor {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Codec defines the interface gRPC uses to encode and decode messages.
+// Note that implementations of this interface must be thread safe;
+// a Codec's methods can be called from concurrent goroutines.
+//
+// Deprecated: use CodecV2 instead.
+type Codec interface {
+	// Marshal returns the wire format of v.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal parses the wire format into v.
+	Unmarshal(data []byte, v any) error
+	// Name returns the name of the Codec implementation. The returned
+	// string will be used as part of content type in transmission.
+	Name() string
+}
+
+var registeredCodecs = make(map[string]Codec)
+
+// RegisterCodec registers the provided Codec for use with all gRPC clients
+// and servers.
+//
+// NOTE: this function must only be called during initialization time
+// (i.e. in an init() function), and is not thread-safe.
+//
+// Deprecated: use RegisterCodecV2 instead.
+func RegisterCodec(codec Codec) {
+	contentSubtype := strings.ToLower(codec.Name())
+	registeredCodecs[contentSubtype] = codec
+}
+
+// GetCodec gets a registered Codec by content-subtype, or nil if no Codec
+// is registered for the content-subtype.
+//
+// Deprecated: use GetCodecV2 instead.
+func GetCodec(contentSubtype string) Codec {
+	return registeredCodecs[contentSubtype]
+}
+
+// CodecV2 defines the interface gRPC uses to encode and decode messages. It
+// differs from Codec in that it works with mem.BufferSlice instead of
+// []byte, so an implementation can marshal and unmarshal directly into
+// pooled, pre-sized buffers instead of allocating one flat slice per
+// message.
+//
+// Note that implementations of this interface must be thread safe; a
+// CodecV2's methods can be called from concurrent goroutines.
+type CodecV2 interface {
+	// Marshal returns the wire format of v. The returned mem.BufferSlice
+	// and its underlying buffers are owned by the caller, who is
+	// responsible for freeing it via BufferSlice.Free().
+	Marshal(v any) (mem.BufferSlice, error)
+	// Unmarshal parses the wire format into v. data is owned by the
+	// caller and is not retained by Unmarshal after it returns.
+	Unmarshal(data mem.BufferSlice, v any) error
+	// Name returns the name of the Codec implementation. The returned
+	// string will be used as part of content type in transmission.
+	Name() string
+}
+
+var registeredCodecsV2 = make(map[string]CodecV2)
+
+// RegisterCodecV2 registers the provided CodecV2 for use with all gRPC
+// clients and servers.
+//
+// NOTE: this function must only be called during initialization time
+// (i.e. in an init() function), and is not thread-safe.
+func RegisterCodecV2(codec CodecV2) {
+	if codec == nil {
+		panic("cannot register a nil CodecV2")
+	}
+	if codec.Name() == "" {
+		panic("cannot register CodecV2 with empty string result for Name()")
+	}
+	contentSubtype := strings.ToLower(codec.Name())
+	registeredCodecsV2[contentSubtype] = codec
+}
+
+// GetCodecV2 gets a registered CodecV2 by content-subtype, or nil if no
+// CodecV2 is registered for the content-subtype.
+//
+// If only a legacy Codec is registered for contentSubtype, GetCodecV2
+// returns a compatibility shim that copies between mem.BufferSlice and
+// []byte around that Codec, so legacy RegisterCodec callers keep working
+// unmodified against the CodecV2 call path.
+func GetCodecV2(contentSubtype string) CodecV2 {
+	if codec, ok := registeredCodecsV2[contentSubtype]; ok {
+		return codec
+	}
+	if codec, ok := registeredCodecs[contentSubtype]; ok {
+		return newCodecV1Bridge(codec)
+	}
+	return nil
+}
+
+// codecV1Bridge adapts a legacy Codec to the CodecV2 interface by copying
+// through a flat []byte. It exists purely for compatibility: callers that
+// want the zero-copy, pooled-buffer benefits of CodecV2 must register one
+// directly (see encoding/proto for the bundled example).
+type codecV1Bridge struct {
+	Codec
+}
+
+func newCodecV1Bridge(c Codec) CodecV2 {
+	return codecV1Bridge{c}
+}
+
+func (v1Bridge codecV1Bridge) Marshal(v any) (mem.BufferSlice, error) {
+	data, err := v1Bridge.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return mem.BufferSlice{mem.NewBuffer(&data, nil)}, nil
+}
+
+func (v1Bridge codecV1Bridge) Unmarshal(data mem.BufferSlice, v any) error {
+	return v1Bridge.Codec.Unmarshal(data.Materialize(), v)
+}
@@ -0,0 +1,127 @@
+/*
+ *
+ * Copyright 2026 gRPC authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package lz4 implements and registers the lz4 compressor during the
+// initialization.
+package lz4
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pierrec/lz4/v4"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// Name is the name registered for the lz4 compressor.
+const Name = "lz4"
+
+func init() {
+	c := &compressor{}
+	c.poolCompressor.New = func() any {
+		w := lz4.NewWriter(io.Discard)
+		return &writer{Writer: w, pool: &c.poolCompressor}
+	}
+	encoding.RegisterCompressor(c)
+}
+
+type writer struct {
+	*lz4.Writer
+	pool *sync.Pool
+}
+
+// SetLevel updates the registered lz4 compressor to use the compression
+// level specified, in [0, 9], where 0 means "fastest" and 9 means "smallest
+// output", matching lz4.CompressionLevel's fastest-to-slowest ordering. The
+// error returned will be nil if the level is valid.
+//
+// NOTE: this function must only be called during initialization time (i.e.
+// in an init() function), and is not thread-safe. It replaces the New func
+// on the shared compressor pool, so *writers already sitting in the pool
+// keep compressing at the level they were created with; it only takes
+// effect for writers the pool creates afterwards.
+func SetLevel(level int) error {
+	if level < 0 || level > 9 {
+		return fmt.Errorf("invalid lz4 compression level: %d", level)
+	}
+	c := encoding.GetCompressor(Name).(*compressor)
+	c.poolCompressor.New = func() any {
+		w := lz4.NewWriter(io.Discard)
+		_ = w.Apply(lz4.CompressionLevelOption(levelToOption(level)))
+		return &writer{Writer: w, pool: &c.poolCompressor}
+	}
+	return nil
+}
+
+// levelToOption maps a 0-9 user-facing level onto lz4's CompressionLevel
+// constants, which are spaced non-linearly (Fast, Level1...Level9).
+func levelToOption(level int) lz4.CompressionLevel {
+	switch {
+	case level <= 0:
+		return lz4.Fast
+	case level >= 9:
+		return lz4.Level9
+	default:
+		return lz4.CompressionLevel(1 << (8 + level))
+	}
+}
+
+func (c *writer) Close() error {
+	defer c.pool.Put(c)
+	return c.Writer.Close()
+}
+
+type compressor struct {
+	poolCompressor   sync.Pool
+	poolDecompressor sync.Pool
+}
+
+func (c *compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	wr := c.poolCompressor.Get().(*writer)
+	wr.Writer.Reset(w)
+	return wr, nil
+}
+
+type reader struct {
+	*lz4.Reader
+	pool *sync.Pool
+}
+
+func (r *reader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if err == io.EOF {
+		r.pool.Put(r)
+	}
+	return n, err
+}
+
+func (c *compressor) Decompress(r io.Reader) (io.Reader, error) {
+	z, inPool := c.poolDecompressor.Get().(*reader)
+	if !inPool {
+		newZ := lz4.NewReader(r)
+		return &reader{Reader: newZ, pool: &c.poolDecompressor}, nil
+	}
+	z.Reader.Reset(r)
+	return z, nil
+}
+
+func (c *compressor) Name() string {
+	return Name
+}
@@ -0,0 +1,103 @@
+/*
+ *
+ * Copyright 2026 gRPC authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package lz4_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/lz4"
+)
+
+func compress(data []byte) ([]byte, error) {
+	cp := encoding.GetCompressor("lz4")
+	var b bytes.Buffer
+	w, err := cp.Compress(&b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	cp := encoding.GetCompressor("lz4")
+	r, err := cp.Decompress(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestLZ4RoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte("grpc-lz4-roundtrip"), 1024)
+	compressed, err := compress(payload)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	if len(compressed) >= len(payload) {
+		t.Fatalf("compressed payload (%d bytes) not smaller than input (%d bytes)", len(compressed), len(payload))
+	}
+	got, err := decompress(compressed)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("round-tripped payload does not match: got %d bytes, want %d bytes", len(got), len(payload))
+	}
+}
+
+// Benchmarks for the lz4 compressor live alongside gzip's in
+// rpc_util_test.go, sharing the bmCompressor table instead of a
+// package-local harness.
+
+func TestLZ4SetLevel(t *testing.T) {
+	defer lz4.SetLevel(0)
+
+	for _, level := range []int{0, 1, 5, 9} {
+		if err := lz4.SetLevel(level); err != nil {
+			t.Errorf("SetLevel(%d) = %v, want nil", level, err)
+		}
+		payload := bytes.Repeat([]byte("grpc-lz4-setlevel"), 1024)
+		compressed, err := compress(payload)
+		if err != nil {
+			t.Fatalf("compress failed after SetLevel(%d): %v", level, err)
+		}
+		got, err := decompress(compressed)
+		if err != nil {
+			t.Fatalf("decompress failed after SetLevel(%d): %v", level, err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Fatalf("round trip after SetLevel(%d) does not match", level)
+		}
+	}
+
+	for _, level := range []int{-1, 10} {
+		if err := lz4.SetLevel(level); err == nil {
+			t.Errorf("SetLevel(%d) = nil, want non-nil error", level)
+		}
+	}
+}
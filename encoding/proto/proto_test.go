@@ -0,0 +1,74 @@
+/*
+ *
+ * Copyright 2026 gRPC authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package proto_test
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+	protoenc "google.golang.org/grpc/encoding/proto"
+	perfpb "google.golang.org/grpc/test/codec_perf"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestRoundTrip verifies that a non-trivial message survives a
+// Marshal/Unmarshal round trip through the pooled CodecV2 implementation
+// with its contents intact.
+func TestRoundTrip(t *testing.T) {
+	cdc := encoding.GetCodecV2(protoenc.Name)
+	want := &perfpb.Buffer{Body: []byte("the quick brown fox jumps over the lazy dog")}
+
+	data, err := cdc.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", want, err)
+	}
+	defer data.Free()
+
+	got := &perfpb.Buffer{}
+	if err := cdc.Unmarshal(data, got); err != nil {
+		t.Fatalf("Unmarshal(%v) failed: %v", data, err)
+	}
+	if !proto.Equal(want, got) {
+		t.Fatalf("round trip = %v; want %v", got, want)
+	}
+}
+
+// TestMarshalNonProtoMessage verifies that Marshal rejects a value that
+// does not implement proto.Message instead of panicking.
+func TestMarshalNonProtoMessage(t *testing.T) {
+	cdc := encoding.GetCodecV2(protoenc.Name)
+	if _, err := cdc.Marshal("not a proto.Message"); err == nil {
+		t.Fatal("Marshal(non-proto.Message) = <nil> error, want non-nil")
+	}
+}
+
+// TestUnmarshalNonProtoMessage verifies that Unmarshal rejects a value that
+// does not implement proto.Message instead of panicking.
+func TestUnmarshalNonProtoMessage(t *testing.T) {
+	cdc := encoding.GetCodecV2(protoenc.Name)
+	data, err := cdc.Marshal(&perfpb.Buffer{Body: []byte("x")})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	defer data.Free()
+
+	if err := cdc.Unmarshal(data, "not a proto.Message"); err == nil {
+		t.Fatal("Unmarshal(_, non-proto.Message) = <nil> error, want non-nil")
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ *
+ * Copyright 2026 gRPC authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package snappy_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/snappy"
+)
+
+func compress(data []byte) ([]byte, error) {
+	cp := encoding.GetCompressor("snappy")
+	var b bytes.Buffer
+	w, err := cp.Compress(&b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	cp := encoding.GetCompressor("snappy")
+	r, err := cp.Decompress(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte("grpc-snappy-roundtrip"), 1024)
+	compressed, err := compress(payload)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	if len(compressed) >= len(payload) {
+		t.Fatalf("compressed payload (%d bytes) not smaller than input (%d bytes)", len(compressed), len(payload))
+	}
+	got, err := decompress(compressed)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("round-tripped payload does not match: got %d bytes, want %d bytes", len(got), len(payload))
+	}
+}
+
+// Benchmarks for the snappy compressor live alongside gzip's in
+// rpc_util_test.go, sharing the bmCompressor table instead of a
+// package-local harness.
@@ -0,0 +1,644 @@
+/*
+ *
+ * Copyright 2014 gRPC authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package grpc
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/internal/transport"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// Compressor defines the interface gRPC uses to compress a message.
+//
+// Deprecated: use package encoding.
+type Compressor interface {
+	// Do compresses p into w.
+	Do(w io.Writer, p []byte) error
+	// Type returns the compression algorithm the Compressor uses.
+	Type() string
+}
+
+type gzipCompressor struct {
+	pool sync.Pool
+}
+
+// NewGZIPCompressor creates a Compressor based on GZIP.
+//
+// Deprecated: use package encoding/gzip.
+func NewGZIPCompressor() Compressor {
+	c, _ := NewGZIPCompressorWithLevel(gzip.DefaultCompression)
+	return c
+}
+
+// NewGZIPCompressorWithLevel is like NewGZIPCompressor but specifies the
+// compression level instead of assuming DefaultCompression.
+//
+// The error returned will be nil if the level is valid.
+//
+// Deprecated: use package encoding/gzip.
+func NewGZIPCompressorWithLevel(level int) (Compressor, error) {
+	if level < gzip.DefaultCompression || level > gzip.BestCompression {
+		return nil, fmt.Errorf("grpc: invalid compression level: %d", level)
+	}
+	return &gzipCompressor{
+		pool: sync.Pool{
+			New: func() any {
+				w, err := gzip.NewWriterLevel(ioutil.Discard, level)
+				if err != nil {
+					panic(err)
+				}
+				return w
+			},
+		},
+	}, nil
+}
+
+func (c *gzipCompressor) Do(w io.Writer, p []byte) error {
+	z := c.pool.Get().(*gzip.Writer)
+	defer c.pool.Put(z)
+	z.Reset(w)
+	if _, err := z.Write(p); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+func (c *gzipCompressor) Type() string {
+	return "gzip"
+}
+
+// Decompressor defines the interface gRPC uses to decompress a message.
+//
+// Deprecated: use package encoding.
+type Decompressor interface {
+	// Do reads the data from r and uncompresses it.
+	Do(r io.Reader) ([]byte, error)
+	// Type returns the compression algorithm the Decompressor uses.
+	Type() string
+}
+
+type gzipDecompressor struct {
+	pool sync.Pool
+}
+
+// NewGZIPDecompressor creates a Decompressor based on GZIP.
+//
+// Deprecated: use package encoding/gzip.
+func NewGZIPDecompressor() Decompressor {
+	return &gzipDecompressor{}
+}
+
+func (d *gzipDecompressor) Do(r io.Reader) ([]byte, error) {
+	var z *gzip.Reader
+	switch maybeZ := d.pool.Get().(type) {
+	case nil:
+		newZ, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		z = newZ
+	case *gzip.Reader:
+		z = maybeZ
+		if err := z.Reset(r); err != nil {
+			d.pool.Put(z)
+			return nil, err
+		}
+	}
+
+	defer func() {
+		z.Close()
+		d.pool.Put(z)
+	}()
+	return ioutil.ReadAll(z)
+}
+
+func (d *gzipDecompressor) Type() string {
+	return "gzip"
+}
+
+// callInfo contains all related configuration and information about an RPC.
+type callInfo struct {
+	compressorType        string
+	compressorNames       []string
+	failFast              bool
+	maxReceiveMessageSize *int
+	maxSendMessageSize    *int
+	creds                 credentialsPerRPC
+	contentSubtype        string
+	codec                 baseCodec
+	maxRetryRPCBufferSize int
+}
+
+// acceptEncoding returns the value to advertise in the grpc-accept-encoding
+// header for this call. UseCompressorList takes priority over UseCompressor
+// so a caller can express a full quality-ordered preference list; with
+// neither set, this is empty and no accept-encoding header is sent.
+func (c *callInfo) acceptEncoding() string {
+	if len(c.compressorNames) > 0 {
+		return strings.Join(c.compressorNames, ",")
+	}
+	return c.compressorType
+}
+
+func defaultCallInfo() *callInfo {
+	return &callInfo{
+		failFast:              true,
+		maxRetryRPCBufferSize: 256 * 1024, // 256KB
+	}
+}
+
+// CallOption configures a Call before it starts or extracts information from
+// a Call after it completes.
+type CallOption interface {
+	// before is called before the call is sent to any server. If before
+	// returns a non-nil error, the RPC fails with that error.
+	before(*callInfo) error
+
+	// after is called after the call has completed. after cannot return an
+	// error, so any failures should be reported via output parameters.
+	after(*callInfo, *csAttempt)
+}
+
+// EmptyCallOption does not alter the Call configuration.
+// It can be embedded in another structure to carry satellite data for use
+// by interceptors.
+type EmptyCallOption struct{}
+
+func (EmptyCallOption) before(*callInfo) error      { return nil }
+func (EmptyCallOption) after(*callInfo, *csAttempt) {}
+
+type credentialsPerRPC interface{}
+
+// UseCompressor returns a CallOption which sets the compressor used when
+// sending the request. If WithCompressor is also set, UseCompressor has
+// higher priority.
+func UseCompressor(name string) CallOption {
+	return CompressorCallOption{CompressorType: name}
+}
+
+// CompressorCallOption is a CallOption that indicates the compressor to use.
+type CompressorCallOption struct {
+	CompressorType string
+}
+
+func (o CompressorCallOption) before(c *callInfo) error {
+	c.compressorType = o.CompressorType
+	return nil
+}
+func (o CompressorCallOption) after(*callInfo, *csAttempt) {}
+
+// UseCompressorList returns a CallOption which sends names, in order of the
+// caller's preference, as the grpc-accept-encoding header for this call.
+// This lets a client say "prefer lz4, fall back to snappy, then gzip, then
+// identity" on a per-RPC basis instead of being pinned to a single codec,
+// which matters when mixing newer codecs with legacy peers that only speak
+// gzip. If set, UseCompressorList takes priority over UseCompressor.
+func UseCompressorList(names ...string) CallOption {
+	return CompressorListCallOption{CompressorNames: names}
+}
+
+// CompressorListCallOption is a CallOption that indicates the ordered list
+// of compressors the client is willing to accept for this call.
+type CompressorListCallOption struct {
+	CompressorNames []string
+}
+
+func (o CompressorListCallOption) before(c *callInfo) error {
+	c.compressorNames = o.CompressorNames
+	return nil
+}
+func (o CompressorListCallOption) after(*callInfo, *csAttempt) {}
+
+// CallContentSubtype returns a CallOption that will set the content-subtype
+// for a request. The content-subtype will be used to construct the
+// Content-Type header.
+func CallContentSubtype(contentSubtype string) CallOption {
+	return ContentSubtypeCallOption{ContentSubtype: strings.ToLower(contentSubtype)}
+}
+
+// ContentSubtypeCallOption is a CallOption that indicates the content-subtype
+// used for marshaling messages.
+type ContentSubtypeCallOption struct {
+	ContentSubtype string
+}
+
+func (o ContentSubtypeCallOption) before(c *callInfo) error {
+	c.contentSubtype = o.ContentSubtype
+	return nil
+}
+func (o ContentSubtypeCallOption) after(*callInfo, *csAttempt) {}
+
+// ForceCodec returns a CallOption that will set codec to be used for all
+// request and response messages for a call.
+func ForceCodec(codec encoding.CodecV2) CallOption {
+	return ForceCodecCallOption{Codec: codec}
+}
+
+// ForceCodecCallOption is a CallOption that indicates the codec used for
+// marshaling messages.
+type ForceCodecCallOption struct {
+	Codec encoding.CodecV2
+}
+
+func (o ForceCodecCallOption) before(c *callInfo) error {
+	c.codec = o.Codec
+	return nil
+}
+func (o ForceCodecCallOption) after(*callInfo, *csAttempt) {}
+
+// csAttempt is a placeholder for the internal client stream attempt state
+// that CallOptions are allowed to observe after an RPC completes. The full
+// definition lives alongside the client stream implementation; it is
+// referenced here only for the CallOption interface.
+type csAttempt struct{}
+
+// baseCodec captures the functionality of encoding.CodecV2, but is not part
+// of the exported API.
+type baseCodec interface {
+	Marshal(v any) (mem.BufferSlice, error)
+	Unmarshal(data mem.BufferSlice, v any) error
+}
+
+// getCodec returns the baseCodec registered for contentSubtype.
+func getCodec(contentSubtype string) baseCodec {
+	if contentSubtype == "" {
+		contentSubtype = proto.Name
+	}
+	codecV2 := encoding.GetCodecV2(contentSubtype)
+	if codecV2 == nil {
+		return nil
+	}
+	return codecV2
+}
+
+// parser reads complete gRPC messages from the underlying reader.
+type parser struct {
+	// r is the underlying reader.
+	// See the comment on recvMsg for the permissible
+	// error types.
+	r streamReader
+
+	// The header of a gRPC message. Find more detail at
+	// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+	header [5]byte
+
+	// bufferPool is the pool of shared receive buffers.
+	bufferPool mem.BufferPool
+}
+
+// streamReader is the interface the parser needs from the transport stream:
+// a way to read a fixed-size header, and a way to read n bytes as a
+// mem.BufferSlice.
+type streamReader interface {
+	ReadHeader(header []byte) error
+	Read(n int) (mem.BufferSlice, error)
+}
+
+// payloadFormat represents the compression used by a message or a series of
+// messages. The value of payloadFormat is the value of the compression
+// byte in the header of a gRPC message.
+type payloadFormat uint8
+
+const (
+	compressionNone payloadFormat = 0 // no compression
+	compressionMade payloadFormat = 1 // compressed
+)
+
+// recvMsg reads a complete gRPC message from the stream.
+//
+// It returns the message and the payload format byte from its header
+// (compressionNone or compressionMade for a well-formed message; the byte
+// is not validated here), or a non-nil error. The error is either an I/O
+// error from the underlying stream or a codes.ResourceExhausted status
+// error when the message length exceeds maxReceiveMessageSize or maxInt.
+//
+// If there is an error, possible values for the returned payloadFormat
+// should be ignored.
+func (p *parser) recvMsg(maxReceiveMessageSize int) (pf payloadFormat, data mem.BufferSlice, err error) {
+	err = p.r.ReadHeader(p.header[:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	pf = payloadFormat(p.header[0])
+	length := binary.BigEndian.Uint32(p.header[1:])
+
+	if length == 0 {
+		return pf, nil, nil
+	}
+	if int64(length) > int64(maxInt) {
+		return 0, nil, status.Errorf(codes.ResourceExhausted, "grpc: received message larger than max length allowed on current machine (%d vs. %d)", length, maxInt)
+	}
+	if int(length) > maxReceiveMessageSize {
+		return 0, nil, status.Errorf(codes.ResourceExhausted, "grpc: received message larger than max (%d vs. %d)", length, maxReceiveMessageSize)
+	}
+
+	data, err = p.r.Read(int(length))
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return pf, data, nil
+}
+
+const maxInt = math.MaxInt32
+
+// encode serializes msg and returns a buffer containing the message, or an
+// error if it is too large to be transmitted by grpc. If msg is nil, it
+// generates an empty message.
+func encode(c baseCodec, msg any) (mem.BufferSlice, error) {
+	if msg == nil { // NOTE: typed nils will not be caught by this check
+		return nil, nil
+	}
+	data, err := c.Marshal(msg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "grpc: error while marshaling: %v", err.Error())
+	}
+	if uint(data.Len()) > math.MaxUint32 {
+		data.Free()
+		return nil, status.Errorf(codes.ResourceExhausted, "grpc: message too large (%d bytes)", data.Len())
+	}
+	return data, nil
+}
+
+// compress returns the compressed data of the given data if a compressor
+// for the given compressor type is registered. It returns nil if no
+// compressor is registered for the compressor type.
+//
+// If acceptEncoding is non-empty (see callInfo.acceptEncoding), it takes
+// priority over compressor/cp: compress negotiates the actual compressor to
+// use via negotiateCompressor, matching the peer's preference-ordered list
+// against the encoding.Compressor registry. The name of whichever compressor
+// ends up being used is returned alongside the compressed data so the
+// caller can advertise it on the outbound grpc-encoding header next to the
+// payloadFormat byte msgHeader writes.
+func compress(in mem.BufferSlice, acceptEncoding string, compressor Compressor, cp encoding.Compressor, pool mem.BufferPool) (mem.BufferSlice, payloadFormat, string, error) {
+	if acceptEncoding != "" {
+		name, err := negotiateCompressor(acceptEncoding, func(name string) bool { return encoding.GetCompressor(name) != nil }, encoding.RegisteredCompressorNames())
+		if err != nil {
+			return nil, 0, "", err
+		}
+		if name != "" {
+			cp = encoding.GetCompressor(name)
+			compressor = nil
+		}
+	}
+	if compressor == nil && cp == nil {
+		return nil, compressionNone, "", nil
+	}
+	var out mem.BufferSlice
+	writer := mem.NewWriter(&out, pool)
+	defer func() { out.Free() }()
+	var name string
+	if cp != nil {
+		z, err := cp.Compress(writer)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		for _, b := range in {
+			if _, err := z.Write(b.ReadOnlyData()); err != nil {
+				return nil, 0, "", err
+			}
+		}
+		if err := z.Close(); err != nil {
+			return nil, 0, "", err
+		}
+		name = cp.Name()
+	} else {
+		if err := compressor.Do(writer, in.Materialize()); err != nil {
+			return nil, 0, "", err
+		}
+		name = compressor.Type()
+	}
+	res := out
+	out = nil // so the deferred Free doesn't run on the slice we're returning
+	return res, compressionMade, name, nil
+}
+
+// errMsgTooLarge is a sentinel returned internally by boundedDecompressReader
+// once it has produced maxReceiveMessageSize bytes, whether or not the
+// underlying decoder has more to give. It never escapes decompress: the caller
+// uses it to decide whether to report an overflow without ever
+// materializing the bytes that triggered it.
+var errMsgTooLarge = errors.New("grpc: message too large")
+
+// boundedDecompressReader wraps the io.Reader returned by a Decompressor or
+// encoding.Compressor and stops handing out bytes once limit have been
+// produced, so a highly compressible payload (a "decompression bomb") can
+// never force its caller to allocate past limit, regardless of how large
+// the stream claims to be.
+type boundedDecompressReader struct {
+	r     io.Reader
+	limit int64
+	count int64
+}
+
+func (r *boundedDecompressReader) Read(p []byte) (int, error) {
+	if r.count >= r.limit {
+		return 0, errMsgTooLarge
+	}
+	if remaining := r.limit - r.count; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.r.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// decompress streams d through dcReader (as produced by a Decompressor or
+// encoding.Compressor's Decompress), enforcing maxReceiveMessageSize without
+// ever allocating a buffer larger than maxReceiveMessageSize plus one pool
+// chunk. This keeps peak memory bounded even for a decompression bomb whose
+// compressed size is tiny but whose decompressed size is enormous.
+func decompress(dcReader io.Reader, maxReceiveMessageSize int, pool mem.BufferPool) (mem.BufferSlice, error) {
+	bounded := &boundedDecompressReader{r: dcReader, limit: int64(maxReceiveMessageSize)}
+	var out mem.BufferSlice
+	_, err := io.Copy(mem.NewWriter(&out, pool), bounded)
+	switch err {
+	case nil:
+		return out, nil
+	case errMsgTooLarge:
+		// bounded.count == maxReceiveMessageSize here; read one more byte
+		// from the real dcReader (not the bounded wrapper) to tell "exactly
+		// at the limit" apart from "over the limit" before deciding.
+		if overflowErr := checkReceiveMessageOverflow(bounded.count, int64(maxReceiveMessageSize), dcReader); overflowErr != nil {
+			out.Free()
+			return nil, status.Errorf(codes.ResourceExhausted, "grpc: %v", overflowErr)
+		}
+		return out, nil
+	default:
+		out.Free()
+		return nil, status.Errorf(codes.Internal, "grpc: failed to decompress the received message: %v", err)
+	}
+}
+
+const (
+	payloadLen = 1
+	sizeLen    = 4
+	headerLen  = payloadLen + sizeLen
+)
+
+// msgHeader returns a 5-byte header for the message data. If compData is
+// not nil, it means the message is compressed, and the size of compData
+// will be used as the size field of the header, instead of the size of
+// data (which is the uncompressed data).
+func msgHeader(data, compData mem.BufferSlice, pf payloadFormat) (hdr []byte, payload mem.BufferSlice) {
+	hdr = make([]byte, headerLen)
+	hdr[0] = byte(pf)
+
+	var length int
+	if pf == compressionMade {
+		length = compData.Len()
+		payload = compData
+	} else {
+		length = data.Len()
+		payload = data
+	}
+
+	// Write length of payload into buf
+	binary.BigEndian.PutUint32(hdr[payloadLen:], uint32(length))
+	return hdr, payload
+}
+
+func outPayload(client bool, msg any, dataLength, payloadLength int, t time.Time) *stats.OutPayload {
+	return &stats.OutPayload{
+		Client:           client,
+		Payload:          msg,
+		Length:           dataLength,
+		WireLength:       payloadLength + headerLen,
+		CompressedLength: payloadLength,
+		SentTime:         t,
+	}
+}
+
+// checkRecvPayload validates a single already-received message's payload
+// format against recvCompress, the grpc-encoding value that produced it.
+// haveCompressor takes the same func(name string) bool shape negotiateCompressor
+// does, so both functions resolve "does this side support this codec"
+// through the identical predicate instead of each computing their own.
+func checkRecvPayload(pf payloadFormat, recvCompress string, haveCompressor func(name string) bool, isServer bool) *status.Status {
+	switch pf {
+	case compressionNone:
+	case compressionMade:
+		if recvCompress == "" || recvCompress == encoding.Identity {
+			return status.New(codes.Internal, "grpc: compressed flag set with identity or empty encoding")
+		}
+		if !haveCompressor(recvCompress) {
+			if isServer {
+				return status.Newf(codes.Unimplemented, "grpc: Decompressor is not installed for grpc-encoding %q", recvCompress)
+			}
+			return status.Newf(codes.Internal, "grpc: Decompressor is not installed for grpc-encoding %q", recvCompress)
+		}
+	default:
+		return status.Newf(codes.Internal, "grpc: received unexpected payload format %d", pf)
+	}
+	return nil
+}
+
+// negotiateCompressor is checkRecvPayload's counterpart for the receiving
+// side of a call that advertised a grpc-accept-encoding list instead of a
+// single codec: it walks acceptEncoding in the order given and returns the
+// first name for which haveCompressor reports support, mirroring HTTP
+// content negotiation. This lets a client say "prefer lz4, then snappy,
+// then gzip" and still interoperate with a peer that only implements one of
+// those, as opposed to the single hard-coded grpc-encoding value that
+// checkRecvPayload validates once a message has already picked one.
+//
+// An empty or all-identity acceptEncoding yields ("", nil): no compression
+// was requested. If none of the offered names are supported, it returns a
+// codes.Unimplemented status naming both sides' offers.
+func negotiateCompressor(acceptEncoding string, haveCompressor func(name string) bool, supported []string) (string, error) {
+	if acceptEncoding == "" {
+		return "", nil
+	}
+	offered := false
+	for _, offer := range strings.Split(acceptEncoding, ",") {
+		name := strings.TrimSpace(offer)
+		if name == "" || name == encoding.Identity {
+			continue
+		}
+		offered = true
+		if haveCompressor(name) {
+			return name, nil
+		}
+	}
+	if !offered {
+		return "", nil
+	}
+	return "", status.Errorf(codes.Unimplemented, "grpc: no mutually supported compressor found: client offered %q, server supports %q", acceptEncoding, strings.Join(supported, ","))
+}
+
+// checkReceiveMessageOverflow checks whether the already-read byte count,
+// plus whatever is still buffered in dcReader, would put the decompressed
+// message over maxReceiveMessageSize. It reads at most one extra byte from
+// dcReader to make this determination, so it does not itself allocate an
+// unbounded amount of memory.
+func checkReceiveMessageOverflow(readBytes int64, maxReceiveMessageSize int64, dcReader io.Reader) error {
+	// Check if the limit has already been exceeded.
+	if readBytes > maxReceiveMessageSize {
+		return fmt.Errorf("overflow: message larger than max size receivable by client (%d bytes)", maxReceiveMessageSize)
+	}
+	// Check if there is additional data beyond the limit by trying to read
+	// one more byte.
+	if readBytes == maxReceiveMessageSize {
+		remainingBuf := make([]byte, 1)
+		bytesRead, _ := dcReader.Read(remainingBuf)
+		if bytesRead > 0 {
+			return fmt.Errorf("overflow: message larger than max size receivable by client (%d bytes)", maxReceiveMessageSize)
+		}
+	}
+	return nil
+}
+
+// toRPCErr converts an error into an error from the status package.
+func toRPCErr(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch e := err.(type) {
+	case transport.ConnectionError:
+		return status.Error(codes.Unavailable, e.Desc)
+	case *transport.NewStreamError:
+		return toRPCErr(e.Err)
+	}
+	if err == io.ErrUnexpectedEOF {
+		return status.Error(codes.Internal, io.ErrUnexpectedEOF.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
@@ -31,7 +31,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/lz4"
 	protoenc "google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/encoding/snappy"
 	"google.golang.org/grpc/internal/testutils"
 	"google.golang.org/grpc/internal/transport"
 	"google.golang.org/grpc/mem"
@@ -259,6 +261,69 @@ func BenchmarkEncode1MiB(b *testing.B) {
 	bmEncode(b, 1024*1024)
 }
 
+// legacyProtoCodec implements the deprecated, copy-through encoding.Codec
+// interface (flat []byte in, flat []byte out) instead of encoding.CodecV2,
+// so its benchmarks below show the cost GetCodecV2's compatibility shim
+// still pays on behalf of codecs that haven't been ported to the pooled,
+// mem.BufferSlice-based path that encoding/proto now uses.
+type legacyProtoCodec struct{}
+
+func (legacyProtoCodec) Marshal(v any) ([]byte, error) {
+	return proto.Marshal(v.(proto.Message))
+}
+
+func (legacyProtoCodec) Unmarshal(data []byte, v any) error {
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (legacyProtoCodec) Name() string {
+	return "proto_legacy_bench"
+}
+
+func init() {
+	encoding.RegisterCodec(legacyProtoCodec{})
+}
+
+// bmEncodeLegacy mirrors bmEncode but runs through legacyProtoCodec's
+// copy-through adapter instead of encoding/proto's pooled CodecV2, so the
+// two benchmark tables can be compared side-by-side.
+func bmEncodeLegacy(b *testing.B, mSize int) {
+	cdc := getCodec("proto_legacy_bench")
+	msg := &perfpb.Buffer{Body: make([]byte, mSize)}
+	encodeData, _ := encode(cdc, msg)
+	encodedSz := int64(encodeData.Len())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		encode(cdc, msg)
+	}
+	b.SetBytes(encodedSz)
+}
+
+func BenchmarkEncodeLegacy1B(b *testing.B) {
+	bmEncodeLegacy(b, 1)
+}
+
+func BenchmarkEncodeLegacy1KiB(b *testing.B) {
+	bmEncodeLegacy(b, 1024)
+}
+
+func BenchmarkEncodeLegacy8KiB(b *testing.B) {
+	bmEncodeLegacy(b, 8*1024)
+}
+
+func BenchmarkEncodeLegacy64KiB(b *testing.B) {
+	bmEncodeLegacy(b, 64*1024)
+}
+
+func BenchmarkEncodeLegacy512KiB(b *testing.B) {
+	bmEncodeLegacy(b, 512*1024)
+}
+
+func BenchmarkEncodeLegacy1MiB(b *testing.B) {
+	bmEncodeLegacy(b, 1024*1024)
+}
+
 // bmCompressor benchmarks a compressor of a Protocol Buffer message containing
 // mSize bytes.
 func bmCompressor(b *testing.B, mSize int, cp Compressor) {
@@ -296,6 +361,84 @@ func BenchmarkGZIPCompressor1MiB(b *testing.B) {
 	bmCompressor(b, 1024*1024, NewGZIPCompressor())
 }
 
+// encodingCompressorAdapter adapts an encoding.Compressor (the interface
+// lz4 and snappy implement) to the legacy Compressor interface so lz4 and
+// snappy can share bmCompressor's benchmark table with gzip above.
+type encodingCompressorAdapter struct {
+	cp encoding.Compressor
+}
+
+func (a encodingCompressorAdapter) Do(w io.Writer, p []byte) error {
+	wc, err := a.cp.Compress(w)
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write(p); err != nil {
+		return err
+	}
+	return wc.Close()
+}
+
+func (a encodingCompressorAdapter) Type() string {
+	return a.cp.Name()
+}
+
+func lz4Compressor() Compressor {
+	return encodingCompressorAdapter{cp: encoding.GetCompressor(lz4.Name)}
+}
+
+func snappyCompressor() Compressor {
+	return encodingCompressorAdapter{cp: encoding.GetCompressor(snappy.Name)}
+}
+
+func BenchmarkLZ4Compressor1B(b *testing.B) {
+	bmCompressor(b, 1, lz4Compressor())
+}
+
+func BenchmarkLZ4Compressor1KiB(b *testing.B) {
+	bmCompressor(b, 1024, lz4Compressor())
+}
+
+func BenchmarkLZ4Compressor8KiB(b *testing.B) {
+	bmCompressor(b, 8*1024, lz4Compressor())
+}
+
+func BenchmarkLZ4Compressor64KiB(b *testing.B) {
+	bmCompressor(b, 64*1024, lz4Compressor())
+}
+
+func BenchmarkLZ4Compressor512KiB(b *testing.B) {
+	bmCompressor(b, 512*1024, lz4Compressor())
+}
+
+func BenchmarkLZ4Compressor1MiB(b *testing.B) {
+	bmCompressor(b, 1024*1024, lz4Compressor())
+}
+
+func BenchmarkSnappyCompressor1B(b *testing.B) {
+	bmCompressor(b, 1, snappyCompressor())
+}
+
+func BenchmarkSnappyCompressor1KiB(b *testing.B) {
+	bmCompressor(b, 1024, snappyCompressor())
+}
+
+func BenchmarkSnappyCompressor8KiB(b *testing.B) {
+	bmCompressor(b, 8*1024, snappyCompressor())
+}
+
+func BenchmarkSnappyCompressor64KiB(b *testing.B) {
+	bmCompressor(b, 64*1024, snappyCompressor())
+}
+
+func BenchmarkSnappyCompressor512KiB(b *testing.B) {
+	bmCompressor(b, 512*1024, snappyCompressor())
+}
+
+func BenchmarkSnappyCompressor1MiB(b *testing.B) {
+	bmCompressor(b, 1024*1024, snappyCompressor())
+}
+
 func TestCheckReceiveMessageOverflow(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -345,6 +488,73 @@ func TestCheckReceiveMessageOverflow(t *testing.T) {
 	}
 }
 
+// TestDecompressBomb verifies that decompressing a highly compressible
+// payload (about 100 KB of gzip data that inflates to 100 MiB) through
+// decompress fails with codes.ResourceExhausted once more than
+// maxReceiveMessageSize decompressed bytes are available, rather than
+// returning the inflated message.
+func TestDecompressBomb(t *testing.T) {
+	const maxReceiveMessageSize = 1024 * 1024 // 1 MiB
+	const bombSize = 100 * 1024 * 1024        // 100 MiB
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(make([]byte, bombSize)); err != nil {
+		t.Fatalf("failed to build decompression bomb: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+	if compressed.Len() >= maxReceiveMessageSize {
+		t.Fatalf("test setup error: compressed payload (%d bytes) is not small", compressed.Len())
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+
+	out, decErr := decompress(r, maxReceiveMessageSize, mem.DefaultBufferPool())
+	if decErr == nil {
+		out.Free()
+		t.Fatalf("decompress(_, %d, _) = _, <nil>; want a ResourceExhausted error", maxReceiveMessageSize)
+	}
+	if s, ok := status.FromError(decErr); !ok || s.Code() != codes.ResourceExhausted {
+		t.Fatalf("decompress(_, %d, _) returned err = %v; want codes.ResourceExhausted", maxReceiveMessageSize, decErr)
+	}
+}
+
+// TestDecompressSuccess verifies the success path through decompress and
+// boundedDecompressReader: a payload comfortably under maxReceiveMessageSize
+// round-trips to the original bytes with no error.
+func TestDecompressSuccess(t *testing.T) {
+	const maxReceiveMessageSize = 1024 * 1024 // 1 MiB
+	payload := []byte("hello world, this is a small gzip payload")
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(payload); err != nil {
+		t.Fatalf("failed to compress payload: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+
+	out, err := decompress(r, maxReceiveMessageSize, mem.DefaultBufferPool())
+	if err != nil {
+		t.Fatalf("decompress(_, %d, _) = _, %v; want nil error", maxReceiveMessageSize, err)
+	}
+	defer out.Free()
+	if got := out.Materialize(); !bytes.Equal(got, payload) {
+		t.Fatalf("decompress(_, %d, _) = %q; want %q", maxReceiveMessageSize, got, payload)
+	}
+}
+
 // // GzipCompressor implements encoding.Compressor for gzip compression.
 // type GzipCompressor struct{}
 
@@ -515,7 +725,7 @@ func TestCheckRecvPayload(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Call the function
-			result := checkRecvPayload(tt.pf, tt.recvCompress, tt.haveCompressor, tt.isServer)
+			result := checkRecvPayload(tt.pf, tt.recvCompress, func(name string) bool { return tt.haveCompressor }, tt.isServer)
 
 			// Check if result is nil for OK status
 			if tt.expectedCode == codes.OK {
@@ -530,6 +740,115 @@ func TestCheckRecvPayload(t *testing.T) {
 	}
 }
 
+func TestNegotiateCompressor(t *testing.T) {
+	supported := []string{"gzip"}
+	haveGZIP := func(name string) bool { return name == "gzip" }
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantName       string
+		wantCode       codes.Code // codes.OK means no error expected
+	}{
+		{
+			name:           "no preference sent",
+			acceptEncoding: "",
+			wantName:       "",
+			wantCode:       codes.OK,
+		},
+		{
+			name:           "no overlap",
+			acceptEncoding: "lz4,snappy",
+			wantCode:       codes.Unimplemented,
+		},
+		{
+			name:           "overlap picks highest-priority match",
+			acceptEncoding: "lz4,gzip,snappy",
+			wantName:       "gzip",
+			wantCode:       codes.OK,
+		},
+		{
+			name:           "identity entries are skipped",
+			acceptEncoding: "identity,gzip",
+			wantName:       "gzip",
+			wantCode:       codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := negotiateCompressor(tt.acceptEncoding, haveGZIP, supported)
+			if tt.wantCode == codes.OK {
+				if err != nil {
+					t.Fatalf("negotiateCompressor(%q) returned unexpected error: %v", tt.acceptEncoding, err)
+				}
+				if name != tt.wantName {
+					t.Fatalf("negotiateCompressor(%q) = %q, want %q", tt.acceptEncoding, name, tt.wantName)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("negotiateCompressor(%q) = %q, <nil>; want an error", tt.acceptEncoding, name)
+			}
+			s, ok := status.FromError(err)
+			if !ok || s.Code() != tt.wantCode {
+				t.Fatalf("negotiateCompressor(%q) returned err = %v; want code %v", tt.acceptEncoding, err, tt.wantCode)
+			}
+		})
+	}
+}
+
+// TestCompressNegotiated exercises the send-side wiring end to end:
+// UseCompressorList populates callInfo.compressorNames,
+// callInfo.acceptEncoding() turns that into the outbound
+// grpc-accept-encoding value, and compress negotiates the actual compressor
+// to use from it via negotiateCompressor instead of requiring the caller to
+// have already resolved one.
+func TestCompressNegotiated(t *testing.T) {
+	c := &callInfo{}
+	if err := (UseCompressorList("lz4", "gzip")).before(c); err != nil {
+		t.Fatalf("UseCompressorList.before() = %v", err)
+	}
+
+	payload := []byte("hello from TestCompressNegotiated")
+	in := mem.BufferSlice{mem.NewBuffer(&payload, nil)}
+
+	out, pf, name, err := compress(in, c.acceptEncoding(), nil, nil, mem.DefaultBufferPool())
+	if err != nil {
+		t.Fatalf("compress(_, %q, _, _, _) returned unexpected error: %v", c.acceptEncoding(), err)
+	}
+	defer out.Free()
+	if pf != compressionMade {
+		t.Fatalf("compress(_, %q, _, _, _) payloadFormat = %v, want compressionMade", c.acceptEncoding(), pf)
+	}
+	if name != "lz4" {
+		t.Fatalf("compress(_, %q, _, _, _) selected compressor %q, want %q (lz4 is first in the preference list)", c.acceptEncoding(), name, "lz4")
+	}
+
+	dcReader, err := encoding.GetCompressor("lz4").Decompress(out.Reader())
+	if err != nil {
+		t.Fatalf("lz4 Decompress failed: %v", err)
+	}
+	got, err := io.ReadAll(dcReader)
+	if err != nil {
+		t.Fatalf("reading decompressed data failed: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("decompressed payload = %q, want %q", got, payload)
+	}
+
+	// Without an accept-encoding list, compress falls back to the legacy,
+	// pre-resolved Compressor exactly as before this negotiation was added.
+	out2, pf2, name2, err := compress(in, "", NewGZIPCompressor(), nil, mem.DefaultBufferPool())
+	if err != nil {
+		t.Fatalf("compress(_, \"\", gzip, _, _) returned unexpected error: %v", err)
+	}
+	defer out2.Free()
+	if pf2 != compressionMade || name2 != "gzip" {
+		t.Fatalf("compress(_, \"\", gzip, _, _) = (_, %v, %q); want (_, compressionMade, %q)", pf2, name2, "gzip")
+	}
+}
+
 // Dummy decompressor function (replace with the actual decompressor from your code)
 func Decompress(r io.Reader) (io.Reader, error) {
 	return gzip.NewReader(r)